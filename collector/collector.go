@@ -17,81 +17,191 @@
 package collector
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
-	"sync"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
-// PipelineServiceCollector struct
-type PipelineServiceCollector struct {
-	logger            log.Logger
-	mutex             sync.Mutex
-	durationScheduled *prometheus.GaugeVec
-	durationCompleted *prometheus.GaugeVec
-}
+// namespace is the leading component of the per-collector scrape metric names.
+const namespace = "pipeline_service"
+
+// Factory builds a subcollector bound to client, scoped to namespaces
+// (empty means all namespaces). It is used both for the long-lived
+// collector serving /pipeline-metrics and for one-shot /probe requests.
+type Factory func(logger log.Logger, client tektonclientset.Interface, namespaces []string) (Collector, error)
+
+var (
+	// factories builds subcollectors for the long-lived, in-cluster
+	// installation served at /pipeline-metrics.
+	factories = make(map[string]Factory)
+	// probeFactories builds subcollectors for one-shot /probe requests.
+	// It defaults to factories unless a subcollector needs a different
+	// implementation for ephemeral scrapes (see pipelinerun.go, where the
+	// shared informer used online would leak a watch goroutine per probe
+	// and return an empty cache on the probe's only scrape).
+	probeFactories     = make(map[string]Factory)
+	collectorState     = make(map[string]*bool)
+	scrapeDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collector_duration_seconds"),
+		"pipeline_service_exporter: Duration of a collector scrape.",
+		[]string{"collector"}, nil,
+	)
+	scrapeSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "collector_success"),
+		"pipeline_service_exporter: Whether a collector succeeded.",
+		[]string{"collector"}, nil,
+	)
+)
 
-func NewCollector(logger log.Logger) (*PipelineServiceCollector, error) {
-	durationScheduled := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "pipelinerun_duration_scheduled_seconds",
-		Help: "Duration in seconds for a PipelineRun to be scheduled.",
-	}, []string{"name", "uid"})
-	durationCompleted := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "pipelinerun_duration_completed_seconds",
-		Help: "Duration in seconds for a PipelineRun to complete.",
-	}, []string{"name", "uid"})
-
-	return &PipelineServiceCollector{
-		logger:            logger,
-		durationScheduled: durationScheduled,
-		durationCompleted: durationCompleted,
-	}, nil
+// Collector is implemented by each subcollector that the PipelineServiceCollector
+// runs on every scrape.
+type Collector interface {
+	// Update sends the collector's metrics onto ch. It should return an error
+	// if the underlying data could not be gathered so that
+	// pipeline_service_scrape_collector_success can reflect the failure.
+	Update(ch chan<- prometheus.Metric) error
 }
 
-// Describe implements the prometheus.Collector interface
-func (c *PipelineServiceCollector) Describe(ch chan<- *prometheus.Desc) {
-	c.durationScheduled.Describe(ch)
-	c.durationCompleted.Describe(ch)
+// registerCollector registers a subcollector factory under name and exposes a
+// --collector.<name> / --no-collector.<name> flag pair to toggle it,
+// following the pattern used by node_exporter and postgres_exporter. The
+// same factory is used for both the online and probe collector.
+func registerCollector(name string, isDefaultEnabled bool, factory Factory) {
+	registerCollectorWithProbe(name, isDefaultEnabled, factory, factory)
 }
 
-// Collect implements the prometheus.Collector interface
-func (c *PipelineServiceCollector) Collect(ch chan<- prometheus.Metric) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	if err := c.collect(ch); err != nil {
-		level.Error(c.logger).Log("msg", "error collecting pipeline-service metrics", "error", err)
+// registerCollectorWithProbe is like registerCollector, but lets a
+// subcollector use a different Factory when it is built for a one-shot
+// /probe request than when it is built for the long-lived online collector.
+func registerCollectorWithProbe(name string, isDefaultEnabled bool, factory, probeFactory Factory) {
+	helpDefaultState := "disabled"
+	if isDefaultEnabled {
+		helpDefaultState = "enabled"
 	}
+
+	flagName := fmt.Sprintf("collector.%s", name)
+	flagHelp := fmt.Sprintf("Enable the %s collector (default: %s).", name, helpDefaultState)
+	defaultValue := fmt.Sprintf("%v", isDefaultEnabled)
+
+	flag := kingpin.Flag(flagName, flagHelp).Default(defaultValue).Bool()
+	collectorState[name] = flag
+
+	factories[name] = factory
+	probeFactories[name] = probeFactory
 }
 
-// collect implements prometheus.Collector interface
-func (c *PipelineServiceCollector) collect(ch chan<- prometheus.Metric) error {
+// PipelineServiceCollector implements the prometheus.Collector interface by
+// fanning out to every enabled subcollector.
+type PipelineServiceCollector struct {
+	Collectors map[string]Collector
+	logger     log.Logger
+
+	// scrapeErrorsTotal is owned by this instance rather than being a
+	// package global: /probe builds one PipelineServiceCollector per
+	// request/target, and a package-global counter would mix one target's
+	// scrape errors into another target's (and the long-lived online
+	// collector's) /probe response.
+	scrapeErrorsTotal *prometheus.CounterVec
+}
 
-	prList, err := getPipelineRuns()
-	if err != nil {
-		level.Error(c.logger).Log("msg", "Error while fetching PipelineRuns", "err", err)
-		return err
-	}
+// NewPipelineServiceCollector builds a long-lived PipelineServiceCollector
+// out of the subcollectors enabled via --collector.<name> flags, optionally
+// restricted to filters (collector names) when non-empty. client is the
+// Tekton typed client each subcollector is built against, normally one
+// built from DefaultRESTConfig for the in-cluster installation.
+func NewPipelineServiceCollector(logger log.Logger, client tektonclientset.Interface, filters ...string) (*PipelineServiceCollector, error) {
+	return newPipelineServiceCollector(logger, client, nil, factories, filters...)
+}
 
-	for _, pipelineRun := range prList.Items {
-		// Fetch and compute the metrics for schedule and completed time
-		scheduledDuration, err := calculateScheduledDuration(pipelineRun)
-		if err != nil {
-			level.Error(c.logger).Log("msg", "Error while calculating the scheduled time of a PipelineRun: ", "err", err)
+// NewProbePipelineServiceCollector builds a PipelineServiceCollector for a
+// single /probe request, scoped to namespaces (empty means all). It uses
+// probeFactories rather than factories so that subcollectors which are
+// unsafe to build fresh per-request (e.g. ones backed by a shared informer
+// that never gets torn down) can supply a one-shot implementation instead.
+func NewProbePipelineServiceCollector(logger log.Logger, client tektonclientset.Interface, namespaces []string, filters ...string) (*PipelineServiceCollector, error) {
+	return newPipelineServiceCollector(logger, client, namespaces, probeFactories, filters...)
+}
+
+func newPipelineServiceCollector(logger log.Logger, client tektonclientset.Interface, namespaces []string, factoryMap map[string]Factory, filters ...string) (*PipelineServiceCollector, error) {
+	f := make(map[string]bool)
+	for _, filter := range filters {
+		enabled, exist := collectorState[filter]
+		if !exist {
+			return nil, fmt.Errorf("missing collector: %s", filter)
+		}
+		if !*enabled {
+			return nil, fmt.Errorf("disabled collector: %s", filter)
 		}
+		f[filter] = true
+	}
 
-		completedDuration, err := calculateCompletedDuration(pipelineRun)
+	collectors := make(map[string]Collector)
+	for name, factory := range factoryMap {
+		if !*collectorState[name] {
+			continue
+		}
+		if len(f) > 0 && !f[name] {
+			continue
+		}
+		collector, err := factory(log.With(logger, "collector", name), client, namespaces)
 		if err != nil {
-			level.Error(c.logger).Log("msg", "Error while calculating the completion time of a PipelineRun: ", "err", err)
+			return nil, err
 		}
+		collectors[name] = collector
+	}
 
-		// Set the metrics
-		c.durationScheduled.WithLabelValues(pipelineRun.Name, string(pipelineRun.UID)).Set(scheduledDuration)
-		c.durationCompleted.WithLabelValues(pipelineRun.Name, string(pipelineRun.UID)).Set(completedDuration)
+	scrapeErrorsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_scrape_errors_total",
+		Help: "Total number of errors encountered while scraping pipeline-service collectors.",
+	}, []string{"collector"})
+
+	return &PipelineServiceCollector{Collectors: collectors, logger: logger, scrapeErrorsTotal: scrapeErrorsTotal}, nil
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *PipelineServiceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- scrapeDurationDesc
+	ch <- scrapeSuccessDesc
+	c.scrapeErrorsTotal.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface, running every
+// enabled subcollector concurrently and emitting a
+// pipeline_service_scrape_collector_success /
+// pipeline_service_scrape_collector_duration_seconds pair for each.
+func (c *PipelineServiceCollector) Collect(ch chan<- prometheus.Metric) {
+	wg := sync.WaitGroup{}
+	wg.Add(len(c.Collectors))
+	for name, collector := range c.Collectors {
+		go func(name string, collector Collector) {
+			defer wg.Done()
+			execute(name, collector, ch, c.logger, c.scrapeErrorsTotal)
+		}(name, collector)
 	}
+	wg.Wait()
+	c.scrapeErrorsTotal.Collect(ch)
+}
 
-	// Make sure it is passed to the channel so that it is exported out
-	c.durationScheduled.Collect(ch)
-	c.durationCompleted.Collect(ch)
+func execute(name string, collector Collector, ch chan<- prometheus.Metric, logger log.Logger, scrapeErrorsTotal *prometheus.CounterVec) {
+	begin := time.Now()
+	err := collector.Update(ch)
+	duration := time.Since(begin)
 
-	return nil
+	var success float64
+	if err != nil {
+		level.Error(logger).Log("msg", "collector failed", "name", name, "duration_seconds", duration.Seconds(), "err", err)
+		scrapeErrorsTotal.WithLabelValues(name).Inc()
+		success = 0
+	} else {
+		level.Debug(logger).Log("msg", "collector succeeded", "name", name, "duration_seconds", duration.Seconds())
+		success = 1
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name)
 }
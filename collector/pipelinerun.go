@@ -0,0 +1,331 @@
+/*
+ Copyright 2023 The Pipeline Service Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package collector
+
+import (
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"k8s.io/client-go/tools/cache"
+)
+
+var pipelinerunHistogramBuckets = kingpin.Flag(
+	"collector.pipelinerun.histogram-buckets",
+	"Histogram bucket (in seconds) for pipelinerun duration metrics; repeat the flag once per bucket.",
+).Default("1", "5", "15", "30", "60", "120", "300", "600", "1800", "3600").Floats()
+
+func init() {
+	registerCollectorWithProbe("pipelinerun", true, NewPipelineRunCollector, NewPipelineRunProbeCollector)
+}
+
+// pipelinerunCollector emits the scheduled/completed duration of Tekton
+// PipelineRuns as histograms fed by PipelineRunInformer, plus a gauge
+// counting the PipelineRuns in the informer's cache (completed ones
+// included) by namespace and status. It no
+// longer keeps a Gauge per PipelineRun UID: that approach grows without
+// bound as PipelineRuns accumulate and re-lists the API server on every
+// scrape.
+type pipelinerunCollector struct {
+	logger            log.Logger
+	informer          *PipelineRunInformer
+	durationScheduled *prometheus.HistogramVec
+	durationCompleted *prometheus.HistogramVec
+	active            *prometheus.GaugeVec
+
+	mutex sync.Mutex // guards active, which Update rebuilds from scratch on every scrape
+}
+
+// NewPipelineRunCollector returns a Collector exposing
+// pipelinerun_duration_scheduled_seconds, pipelinerun_duration_completed_seconds
+// and pipelineruns_active, backed by a shared PipelineRunInformer that
+// watches cluster-wide. It is only used for the long-lived collector behind
+// /pipeline-metrics, so namespaces (meaningful only for per-target /probe
+// scrapes) is ignored here.
+func NewPipelineRunCollector(logger log.Logger, client tektonclientset.Interface, namespaces []string) (Collector, error) {
+	buckets := *pipelinerunHistogramBuckets
+
+	durationScheduled := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipelinerun_duration_scheduled_seconds",
+		Help:    "Duration in seconds for a PipelineRun to be scheduled.",
+		Buckets: buckets,
+	}, []string{"namespace", "status", "reason"})
+	durationCompleted := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipelinerun_duration_completed_seconds",
+		Help:    "Duration in seconds for a PipelineRun to complete.",
+		Buckets: buckets,
+	}, []string{"namespace", "status", "reason"})
+	active := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipelineruns_active",
+		Help: "Number of PipelineRuns currently known to the informer cache, by namespace and status.",
+	}, []string{"namespace", "status"})
+
+	informer, err := NewPipelineRunInformer(logger, client, durationScheduled, durationCompleted)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pipelinerunCollector{
+		logger:            logger,
+		informer:          informer,
+		durationScheduled: durationScheduled,
+		durationCompleted: durationCompleted,
+		active:            active,
+	}, nil
+}
+
+// Update implements the Collector interface. The heavy lifting (observing
+// duration histograms on PipelineRun status transitions) happens out-of-band
+// in the informer's event handlers; Update only has to export the resulting
+// histograms and rebuild the active gauge from the informer's cache.
+func (c *pipelinerunCollector) Update(ch chan<- prometheus.Metric) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.active.Reset()
+	for _, count := range c.informer.ActiveCounts() {
+		c.active.WithLabelValues(count.namespace, count.status).Set(float64(count.count))
+	}
+
+	c.durationScheduled.Collect(ch)
+	c.durationCompleted.Collect(ch)
+	c.active.Collect(ch)
+
+	return nil
+}
+
+// activeCount is the number of PipelineRuns in a given namespace/status
+// currently held in the informer's cache.
+type activeCount struct {
+	namespace string
+	status    string
+	count     int
+}
+
+// PipelineRunInformer watches PipelineRuns via a client-go shared informer
+// and, on transitions to Running/Succeeded/Failed, observes the
+// scheduled/completed durations into the supplied histograms. UID is
+// deliberately dropped from the histogram labels to keep cardinality
+// bounded.
+type PipelineRunInformer struct {
+	logger            log.Logger
+	informer          cache.SharedIndexInformer
+	durationScheduled *prometheus.HistogramVec
+	durationCompleted *prometheus.HistogramVec
+
+	mutex     sync.Mutex
+	lastPhase map[string]string // uid -> last observed phase, so we only observe each transition once
+}
+
+// NewPipelineRunInformer builds a PipelineRunInformer over client's shared
+// informer factory and starts watching immediately.
+func NewPipelineRunInformer(logger log.Logger, client tektonclientset.Interface, durationScheduled, durationCompleted *prometheus.HistogramVec) (*PipelineRunInformer, error) {
+	informer := newPipelineRunSharedIndexInformer(client)
+
+	pri := &PipelineRunInformer{
+		logger:            logger,
+		informer:          informer,
+		durationScheduled: durationScheduled,
+		durationCompleted: durationCompleted,
+		lastPhase:         make(map[string]string),
+	}
+
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    pri.handlePipelineRun,
+		UpdateFunc: func(_, newObj interface{}) { pri.handlePipelineRun(newObj) },
+		DeleteFunc: pri.handleDelete,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stopCh := make(chan struct{})
+	go informer.Run(stopCh)
+
+	return pri, nil
+}
+
+func (pri *PipelineRunInformer) handlePipelineRun(obj interface{}) {
+	pipelineRun, ok := toPipelineRun(obj)
+	if !ok {
+		return
+	}
+
+	uid := string(pipelineRun.UID)
+	status, reason := pipelineRunStatusAndReason(pipelineRun)
+
+	pri.mutex.Lock()
+	previous := pri.lastPhase[uid]
+	pri.mutex.Unlock()
+
+	if previous == status {
+		return
+	}
+
+	// lastPhase is only advanced once the duration for this transition has
+	// actually been observed. A PipelineRun's first Running event typically
+	// arrives before status.StartTime is populated, so calculateScheduledDuration
+	// can fail on that first event; if we recorded lastPhase="Running" anyway,
+	// every later Running update (once StartTime is set) would short-circuit
+	// on the check above and the scheduled duration would never be observed.
+	// Leaving lastPhase untouched on error means the next update for this
+	// PipelineRun retries instead of being silently dropped.
+	switch status {
+	case "Running":
+		scheduledDuration, err := calculateScheduledDuration(pipelineRun)
+		if err != nil {
+			level.Error(pri.logger).Log("msg", "Error while calculating the scheduled time of a PipelineRun", "err", err)
+			return
+		}
+		pri.durationScheduled.WithLabelValues(pipelineRun.Namespace, status, reason).Observe(scheduledDuration)
+	case "Succeeded", "Failed":
+		completedDuration, err := calculateCompletedDuration(pipelineRun)
+		if err != nil {
+			level.Error(pri.logger).Log("msg", "Error while calculating the completion time of a PipelineRun", "err", err)
+			return
+		}
+		pri.durationCompleted.WithLabelValues(pipelineRun.Namespace, status, reason).Observe(completedDuration)
+	}
+
+	pri.mutex.Lock()
+	pri.lastPhase[uid] = status
+	pri.mutex.Unlock()
+}
+
+func (pri *PipelineRunInformer) handleDelete(obj interface{}) {
+	pipelineRun, ok := toPipelineRun(obj)
+	if !ok {
+		return
+	}
+	pri.mutex.Lock()
+	delete(pri.lastPhase, string(pipelineRun.UID))
+	pri.mutex.Unlock()
+}
+
+// ActiveCounts returns the number of PipelineRuns per namespace/status
+// currently held in the informer's cache.
+func (pri *PipelineRunInformer) ActiveCounts() []activeCount {
+	counts := make(map[string]*activeCount)
+	for _, obj := range pri.informer.GetStore().List() {
+		pipelineRun, ok := toPipelineRun(obj)
+		if !ok {
+			continue
+		}
+		status, _ := pipelineRunStatusAndReason(pipelineRun)
+		key := pipelineRun.Namespace + "/" + status
+		if c, exists := counts[key]; exists {
+			c.count++
+		} else {
+			counts[key] = &activeCount{namespace: pipelineRun.Namespace, status: status, count: 1}
+		}
+	}
+
+	result := make([]activeCount, 0, len(counts))
+	for _, c := range counts {
+		result = append(result, *c)
+	}
+	return result
+}
+
+// pipelinerunProbeCollector is the /probe counterpart to pipelinerunCollector.
+// A probe request builds, uses and discards its PipelineServiceCollector
+// within a single HTTP request, so it cannot use the informer: the
+// informer's cache would still be empty by the time Update ran, and its
+// watch goroutine would never be stopped once the request completed. This
+// collector instead lists PipelineRuns directly on every Update call.
+type pipelinerunProbeCollector struct {
+	logger            log.Logger
+	client            tektonclientset.Interface
+	namespaces        []string
+	durationScheduled *prometheus.HistogramVec
+	durationCompleted *prometheus.HistogramVec
+	active            *prometheus.GaugeVec
+}
+
+// NewPipelineRunProbeCollector returns a Collector exposing the same
+// metrics as NewPipelineRunCollector, but computed from a direct,
+// namespaces-scoped list on every scrape rather than a long-lived informer.
+func NewPipelineRunProbeCollector(logger log.Logger, client tektonclientset.Interface, namespaces []string) (Collector, error) {
+	buckets := *pipelinerunHistogramBuckets
+
+	return &pipelinerunProbeCollector{
+		logger:     logger,
+		client:     client,
+		namespaces: namespaces,
+		durationScheduled: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pipelinerun_duration_scheduled_seconds",
+			Help:    "Duration in seconds for a PipelineRun to be scheduled.",
+			Buckets: buckets,
+		}, []string{"namespace", "status", "reason"}),
+		durationCompleted: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pipelinerun_duration_completed_seconds",
+			Help:    "Duration in seconds for a PipelineRun to complete.",
+			Buckets: buckets,
+		}, []string{"namespace", "status", "reason"}),
+		active: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pipelineruns_active",
+			Help: "Number of PipelineRuns returned by the probe's list, by namespace and status.",
+		}, []string{"namespace", "status"}),
+	}, nil
+}
+
+// Update implements the Collector interface.
+func (c *pipelinerunProbeCollector) Update(ch chan<- prometheus.Metric) error {
+	prList, err := listPipelineRuns(c.client, c.namespaces)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "Error while fetching PipelineRuns", "err", err)
+		return err
+	}
+
+	type namespaceStatus struct{ namespace, status string }
+	active := make(map[namespaceStatus]int)
+	for _, pipelineRun := range prList.Items {
+		pipelineRun := pipelineRun
+		status, reason := pipelineRunStatusAndReason(&pipelineRun)
+		active[namespaceStatus{namespace: pipelineRun.Namespace, status: status}]++
+
+		switch status {
+		case "Running":
+			scheduledDuration, err := calculateScheduledDuration(&pipelineRun)
+			if err != nil {
+				level.Error(c.logger).Log("msg", "Error while calculating the scheduled time of a PipelineRun", "err", err)
+				continue
+			}
+			c.durationScheduled.WithLabelValues(pipelineRun.Namespace, status, reason).Observe(scheduledDuration)
+		case "Succeeded", "Failed":
+			completedDuration, err := calculateCompletedDuration(&pipelineRun)
+			if err != nil {
+				level.Error(c.logger).Log("msg", "Error while calculating the completion time of a PipelineRun", "err", err)
+				continue
+			}
+			c.durationCompleted.WithLabelValues(pipelineRun.Namespace, status, reason).Observe(completedDuration)
+		}
+	}
+
+	for key, count := range active {
+		c.active.WithLabelValues(key.namespace, key.status).Set(float64(count))
+	}
+
+	c.durationScheduled.Collect(ch)
+	c.durationCompleted.Collect(ch)
+	c.active.Collect(ch)
+
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+ Copyright 2023 The Pipeline Service Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"time"
+
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	tektoninformers "github.com/tektoncd/pipeline/pkg/client/informers/externalversions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// resyncPeriod controls how often the shared informer does a full
+// relist-from-cache reconciliation, independent of watch events.
+const resyncPeriod = 10 * time.Minute
+
+// newPipelineRunSharedIndexInformer builds the shared index informer that
+// PipelineRunInformer watches, over the given Tekton typed client.
+func newPipelineRunSharedIndexInformer(client tektonclientset.Interface) cache.SharedIndexInformer {
+	factory := tektoninformers.NewSharedInformerFactory(client, resyncPeriod)
+	return factory.Tekton().V1().PipelineRuns().Informer()
+}
+
+// listPipelineRuns lists PipelineRuns directly via client, scoped to
+// namespaces (or every namespace, if empty). Used by the /probe path, which
+// needs a point-in-time read rather than a long-lived informer cache.
+func listPipelineRuns(client tektonclientset.Interface, namespaces []string) (*pipelinev1.PipelineRunList, error) {
+	if len(namespaces) == 0 {
+		return client.TektonV1().PipelineRuns(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	}
+
+	result := &pipelinev1.PipelineRunList{}
+	for _, namespace := range namespaces {
+		list, err := client.TektonV1().PipelineRuns(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, list.Items...)
+	}
+	return result, nil
+}
+
+// toPipelineRun type-asserts an informer event's object to a PipelineRun.
+func toPipelineRun(obj interface{}) (*pipelinev1.PipelineRun, bool) {
+	pipelineRun, ok := obj.(*pipelinev1.PipelineRun)
+	return pipelineRun, ok
+}
+
+// pipelineRunStatusAndReason derives a coarse status (Running, Succeeded or
+// Failed) and the underlying condition reason from a PipelineRun's status
+// conditions. A missing or Unknown "Succeeded" condition is reported as
+// Running.
+func pipelineRunStatusAndReason(pipelineRun *pipelinev1.PipelineRun) (status string, reason string) {
+	condition := pipelineRun.Status.GetCondition("Succeeded")
+	if condition == nil {
+		return "Running", ""
+	}
+
+	switch condition.Status {
+	case "True":
+		return "Succeeded", condition.Reason
+	case "False":
+		return "Failed", condition.Reason
+	default:
+		return "Running", condition.Reason
+	}
+}
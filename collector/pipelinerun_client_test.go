@@ -0,0 +1,39 @@
+/*
+ Copyright 2023 The Pipeline Service Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package collector
+
+import (
+	"testing"
+
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+func TestPipelineRunStatusAndReason_NoConditionYet(t *testing.T) {
+	// A freshly-created PipelineRun has no "Succeeded" condition until the
+	// controller starts reconciling it; GetCondition returns nil and the
+	// PipelineRun must be reported as Running with no reason.
+	pipelineRun := &pipelinev1.PipelineRun{}
+
+	status, reason := pipelineRunStatusAndReason(pipelineRun)
+
+	if status != "Running" {
+		t.Errorf("status = %q, want %q", status, "Running")
+	}
+	if reason != "" {
+		t.Errorf("reason = %q, want empty", reason)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+ Copyright 2023 The Pipeline Service Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestPipelineRunInformer() *PipelineRunInformer {
+	return &PipelineRunInformer{
+		logger: log.NewNopLogger(),
+		durationScheduled: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "test_pipelinerun_duration_scheduled_seconds",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"namespace", "status", "reason"}),
+		durationCompleted: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "test_pipelinerun_duration_completed_seconds",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"namespace", "status", "reason"}),
+		lastPhase: make(map[string]string),
+	}
+}
+
+// TestHandlePipelineRun_RetriesScheduledDurationUntilObserved guards against
+// the regression where lastPhase was recorded as "Running" even when
+// calculateScheduledDuration failed (StartTime not set yet), which permanently
+// suppressed the scheduled-duration observation for that PipelineRun.
+func TestHandlePipelineRun_RetriesScheduledDurationUntilObserved(t *testing.T) {
+	pri := newTestPipelineRunInformer()
+
+	pipelineRun := &pipelinev1.PipelineRun{}
+	pipelineRun.Namespace = "ns"
+	pipelineRun.Name = "pr"
+	pipelineRun.UID = "uid-1"
+	pipelineRun.CreationTimestamp = metav1.Now()
+
+	// First event: no Conditions yet (status == "Running") and no StartTime,
+	// so calculateScheduledDuration errors.
+	pri.handlePipelineRun(pipelineRun)
+
+	if got := testutil.CollectAndCount(pri.durationScheduled); got != 0 {
+		t.Fatalf("expected no scheduled-duration observation yet, got %d", got)
+	}
+
+	pri.mutex.Lock()
+	_, seen := pri.lastPhase[string(pipelineRun.UID)]
+	pri.mutex.Unlock()
+	if seen {
+		t.Fatalf("lastPhase was advanced despite calculateScheduledDuration failing")
+	}
+
+	// Second event: StartTime is now populated. Because lastPhase was left
+	// untouched above, the still-"Running" status must be retried rather than
+	// suppressed.
+	startTime := metav1.NewTime(pipelineRun.CreationTimestamp.Add(2 * time.Second))
+	pipelineRun.Status.StartTime = &startTime
+	pri.handlePipelineRun(pipelineRun)
+
+	if got := testutil.CollectAndCount(pri.durationScheduled); got != 1 {
+		t.Fatalf("expected scheduled-duration to be observed once StartTime was set, got %d", got)
+	}
+}
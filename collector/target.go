@@ -0,0 +1,122 @@
+/*
+ Copyright 2023 The Pipeline Service Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"os"
+
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"gopkg.in/yaml.v2"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Target describes one Tekton installation a probe request can be routed
+// to: a kubeconfig and context to build a REST config from, and the
+// namespaces it should be scoped to.
+type Target struct {
+	Name       string   `yaml:"name"`
+	Kubeconfig string   `yaml:"kubeconfig"`
+	Context    string   `yaml:"context"`
+	Namespaces []string `yaml:"namespaces"`
+}
+
+// TargetProvider maps a target name, as supplied by a /probe?target=
+// request, to the Tekton installation it refers to.
+type TargetProvider interface {
+	// Target returns the Target registered under name, or an error if none
+	// is registered.
+	Target(name string) (*Target, error)
+	// RESTConfig builds the REST config for the target registered under
+	// name.
+	RESTConfig(name string) (*rest.Config, error)
+}
+
+// fileTargetProvider is a TargetProvider backed by a YAML file listing
+// targets, in the shape blackbox_exporter/snmp_exporter use for their own
+// module configs.
+type fileTargetProvider struct {
+	targets map[string]*Target
+}
+
+// NewFileTargetProvider loads a TargetProvider from a YAML config file
+// containing a top-level `targets` list of {name, kubeconfig, context,
+// namespaces} entries.
+func NewFileTargetProvider(path string) (TargetProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading target config %s: %w", path, err)
+	}
+
+	var config struct {
+		Targets []*Target `yaml:"targets"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parsing target config %s: %w", path, err)
+	}
+
+	targets := make(map[string]*Target, len(config.Targets))
+	for _, target := range config.Targets {
+		targets[target.Name] = target
+	}
+
+	return &fileTargetProvider{targets: targets}, nil
+}
+
+// Target implements TargetProvider.
+func (p *fileTargetProvider) Target(name string) (*Target, error) {
+	target, ok := p.targets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown target: %s", name)
+	}
+	return target, nil
+}
+
+// RESTConfig implements TargetProvider.
+func (p *fileTargetProvider) RESTConfig(name string) (*rest.Config, error) {
+	target, err := p.Target(name)
+	if err != nil {
+		return nil, err
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = target.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: target.Context}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+}
+
+// DefaultRESTConfig returns the in-cluster REST config, falling back to the
+// default kubeconfig loading rules for local development. It is what the
+// exporter uses for its primary /pipeline-metrics endpoint; /probe requests
+// use a target-specific config from a TargetProvider instead.
+func DefaultRESTConfig() (*rest.Config, error) {
+	if config, err := rest.InClusterConfig(); err == nil {
+		return config, nil
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{},
+	).ClientConfig()
+}
+
+// NewClient builds the Tekton typed client each subcollector is run
+// against, for the given REST config.
+func NewClient(config *rest.Config) (tektonclientset.Interface, error) {
+	return tektonclientset.NewForConfig(config)
+}
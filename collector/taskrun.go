@@ -0,0 +1,228 @@
+/*
+ Copyright 2023 The Pipeline Service Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	registerCollector("taskrun", true, NewTaskRunCollector)
+}
+
+// taskrunCollector emits pod-scheduling and step-level duration metrics for
+// Tekton TaskRuns. The scheduled/completed/pod-pending gauges are keyed by
+// namespace/pipelinerun/task/taskrun (taskrun distinguishes standalone
+// TaskRuns, which have empty pipelinerun/task labels, and any TaskRuns that
+// happen to share a pipelineTask name), and Reset before every scrape, so a
+// TaskRun that disappears between scrapes doesn't leave a stale series
+// behind. The step-duration histogram deliberately omits any per-TaskRun
+// identity label, the same way pipelinerun.go's histograms drop uid: a
+// step's duration is folded into the (namespace, pipelinerun, task, step)
+// series it belongs to rather than getting its own series per TaskRun, so
+// the label set stays bounded over the process's lifetime. observedSteps
+// still de-dupes by uid+step internally so a given step's duration is only
+// observed once, not on every subsequent scrape.
+type taskrunCollector struct {
+	logger            log.Logger
+	client            tektonclientset.Interface
+	namespaces        []string
+	durationScheduled *prometheus.GaugeVec
+	durationCompleted *prometheus.GaugeVec
+	podPending        *prometheus.GaugeVec
+	stepDuration      *prometheus.HistogramVec
+	observedSteps     map[string]struct{}
+
+	mutex sync.Mutex // guards observedSteps and the gauges, which Update rebuilds from scratch on every scrape
+}
+
+// NewTaskRunCollector returns a Collector exposing
+// taskrun_duration_scheduled_seconds, taskrun_duration_completed_seconds,
+// taskrun_pod_pending_seconds and taskrun_step_duration_seconds.
+func NewTaskRunCollector(logger log.Logger, client tektonclientset.Interface, namespaces []string) (Collector, error) {
+	labels := []string{"namespace", "pipelinerun", "task", "taskrun"}
+
+	return &taskrunCollector{
+		logger:     logger,
+		client:     client,
+		namespaces: namespaces,
+		durationScheduled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "taskrun_duration_scheduled_seconds",
+			Help: "Duration in seconds for a TaskRun to be scheduled.",
+		}, labels),
+		durationCompleted: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "taskrun_duration_completed_seconds",
+			Help: "Duration in seconds for a TaskRun to complete.",
+		}, labels),
+		podPending: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "taskrun_pod_pending_seconds",
+			Help: "Duration in seconds that a TaskRun's pod spent in the Pending phase.",
+		}, labels),
+		stepDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "taskrun_step_duration_seconds",
+			Help:    "Duration in seconds for a TaskRun step to complete.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"namespace", "pipelinerun", "task", "step"}),
+		observedSteps: make(map[string]struct{}),
+	}, nil
+}
+
+// Update implements the Collector interface.
+func (c *taskrunCollector) Update(ch chan<- prometheus.Metric) error {
+	trList, err := getTaskRuns(c.client, c.namespaces)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "Error while fetching TaskRuns", "err", err)
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.durationScheduled.Reset()
+	c.durationCompleted.Reset()
+	c.podPending.Reset()
+
+	observedSteps := make(map[string]struct{})
+
+	for _, taskRun := range trList.Items {
+		namespace := taskRun.Namespace
+		pipelinerun := taskRun.Labels["tekton.dev/pipelineRun"]
+		task := taskRun.Labels["tekton.dev/pipelineTask"]
+		uid := string(taskRun.UID)
+
+		if scheduledDuration, err := calculateTaskRunScheduledDuration(&taskRun); err != nil {
+			level.Error(c.logger).Log("msg", "Error while calculating the scheduled time of a TaskRun", "err", err)
+		} else {
+			c.durationScheduled.WithLabelValues(namespace, pipelinerun, task, taskRun.Name).Set(scheduledDuration)
+		}
+
+		if completedDuration, err := calculateTaskRunCompletedDuration(&taskRun); err != nil {
+			level.Error(c.logger).Log("msg", "Error while calculating the completion time of a TaskRun", "err", err)
+		} else {
+			c.durationCompleted.WithLabelValues(namespace, pipelinerun, task, taskRun.Name).Set(completedDuration)
+		}
+
+		if podPending, err := calculatePodPendingDuration(&taskRun); err != nil {
+			level.Error(c.logger).Log("msg", "Error while calculating the pod pending time of a TaskRun", "err", err)
+		} else {
+			c.podPending.WithLabelValues(namespace, pipelinerun, task, taskRun.Name).Set(podPending)
+		}
+
+		for _, step := range taskRun.Status.Steps {
+			terminated := step.Terminated
+			if terminated == nil || terminated.StartedAt.IsZero() || terminated.FinishedAt.IsZero() {
+				continue
+			}
+
+			key := uid + "/" + step.Name
+			observedSteps[key] = struct{}{}
+			if _, alreadyObserved := c.observedSteps[key]; alreadyObserved {
+				continue
+			}
+
+			stepDuration := terminated.FinishedAt.Sub(terminated.StartedAt.Time).Seconds()
+			c.stepDuration.WithLabelValues(namespace, pipelinerun, task, step.Name).Observe(stepDuration)
+		}
+	}
+
+	c.observedSteps = observedSteps
+
+	c.durationScheduled.Collect(ch)
+	c.durationCompleted.Collect(ch)
+	c.podPending.Collect(ch)
+	c.stepDuration.Collect(ch)
+
+	return nil
+}
+
+// getTaskRuns lists TaskRuns using client, scoped to namespaces (or every
+// namespace, if empty), rather than relying on package-level client state,
+// so the same subcollector code can serve both the in-cluster installation
+// and ad-hoc /probe targets restricted to a target's namespaces.
+func getTaskRuns(client tektonclientset.Interface, namespaces []string) (*pipelinev1.TaskRunList, error) {
+	if len(namespaces) == 0 {
+		return client.TektonV1().TaskRuns(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	}
+
+	result := &pipelinev1.TaskRunList{}
+	for _, namespace := range namespaces {
+		list, err := client.TektonV1().TaskRuns(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, list.Items...)
+	}
+	return result, nil
+}
+
+// calculateTaskRunScheduledDuration returns the seconds between a TaskRun's
+// creation and its pod being scheduled.
+func calculateTaskRunScheduledDuration(taskRun *pipelinev1.TaskRun) (float64, error) {
+	if taskRun.Status.StartTime == nil {
+		return 0, fmt.Errorf("taskrun %s/%s has not started", taskRun.Namespace, taskRun.Name)
+	}
+	return taskRun.Status.StartTime.Sub(taskRun.CreationTimestamp.Time).Seconds(), nil
+}
+
+// calculateTaskRunCompletedDuration returns the seconds between a TaskRun
+// starting and completing.
+func calculateTaskRunCompletedDuration(taskRun *pipelinev1.TaskRun) (float64, error) {
+	if taskRun.Status.StartTime == nil || taskRun.Status.CompletionTime == nil {
+		return 0, fmt.Errorf("taskrun %s/%s has not completed", taskRun.Namespace, taskRun.Name)
+	}
+	return taskRun.Status.CompletionTime.Sub(taskRun.Status.StartTime.Time).Seconds(), nil
+}
+
+// calculatePodPendingDuration returns the seconds a TaskRun's pod spent
+// Pending: the time between the pod being scheduled and its first step
+// starting.
+func calculatePodPendingDuration(taskRun *pipelinev1.TaskRun) (float64, error) {
+	if taskRun.Status.StartTime == nil {
+		return 0, fmt.Errorf("taskrun %s/%s has not started", taskRun.Namespace, taskRun.Name)
+	}
+
+	var firstStepStart *metav1.Time
+	for _, step := range taskRun.Status.Steps {
+		var started *metav1.Time
+		switch {
+		case step.Terminated != nil:
+			started = &step.Terminated.StartedAt
+		case step.Running != nil:
+			started = &step.Running.StartedAt
+		}
+		if started == nil || started.IsZero() {
+			continue
+		}
+		if firstStepStart == nil || started.Before(firstStepStart) {
+			firstStepStart = started
+		}
+	}
+	if firstStepStart == nil {
+		return 0, fmt.Errorf("taskrun %s/%s has no step start times yet", taskRun.Namespace, taskRun.Name)
+	}
+
+	return firstStepStart.Sub(taskRun.Status.StartTime.Time).Seconds(), nil
+}
@@ -0,0 +1,137 @@
+/*
+ Copyright 2023 The Pipeline Service Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package collector
+
+import (
+	"testing"
+	"time"
+
+	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCalculateTaskRunScheduledDuration(t *testing.T) {
+	created := metav1.Now()
+
+	t.Run("not started", func(t *testing.T) {
+		taskRun := &pipelinev1.TaskRun{}
+		taskRun.Namespace, taskRun.Name = "ns", "tr"
+
+		if _, err := calculateTaskRunScheduledDuration(taskRun); err == nil {
+			t.Fatal("expected an error for a TaskRun with no StartTime")
+		}
+	})
+
+	t.Run("started", func(t *testing.T) {
+		start := metav1.NewTime(created.Add(3 * time.Second))
+		taskRun := &pipelinev1.TaskRun{}
+		taskRun.Namespace, taskRun.Name = "ns", "tr"
+		taskRun.CreationTimestamp = created
+		taskRun.Status.StartTime = &start
+
+		got, err := calculateTaskRunScheduledDuration(taskRun)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != 3 {
+			t.Fatalf("duration = %v, want 3s", got)
+		}
+	})
+}
+
+func TestCalculateTaskRunCompletedDuration(t *testing.T) {
+	start := metav1.Now()
+
+	t.Run("not completed", func(t *testing.T) {
+		taskRun := &pipelinev1.TaskRun{}
+		taskRun.Namespace, taskRun.Name = "ns", "tr"
+		taskRun.Status.StartTime = &start
+
+		if _, err := calculateTaskRunCompletedDuration(taskRun); err == nil {
+			t.Fatal("expected an error for a TaskRun with no CompletionTime")
+		}
+	})
+
+	t.Run("completed", func(t *testing.T) {
+		completion := metav1.NewTime(start.Add(5 * time.Second))
+		taskRun := &pipelinev1.TaskRun{}
+		taskRun.Namespace, taskRun.Name = "ns", "tr"
+		taskRun.Status.StartTime = &start
+		taskRun.Status.CompletionTime = &completion
+
+		got, err := calculateTaskRunCompletedDuration(taskRun)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != 5 {
+			t.Fatalf("duration = %v, want 5s", got)
+		}
+	})
+}
+
+func TestCalculatePodPendingDuration(t *testing.T) {
+	start := metav1.Now()
+
+	t.Run("no steps started yet", func(t *testing.T) {
+		taskRun := &pipelinev1.TaskRun{}
+		taskRun.Namespace, taskRun.Name = "ns", "tr"
+		taskRun.Status.StartTime = &start
+
+		if _, err := calculatePodPendingDuration(taskRun); err == nil {
+			t.Fatal("expected an error when no step has started")
+		}
+	})
+
+	t.Run("first step running", func(t *testing.T) {
+		stepStart := metav1.NewTime(start.Add(2 * time.Second))
+		taskRun := &pipelinev1.TaskRun{}
+		taskRun.Namespace, taskRun.Name = "ns", "tr"
+		taskRun.Status.StartTime = &start
+		taskRun.Status.Steps = []pipelinev1.StepState{
+			{ContainerState: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: stepStart}}},
+		}
+
+		got, err := calculatePodPendingDuration(taskRun)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != 2 {
+			t.Fatalf("duration = %v, want 2s", got)
+		}
+	})
+
+	t.Run("earliest of multiple terminated steps", func(t *testing.T) {
+		earlier := metav1.NewTime(start.Add(1 * time.Second))
+		later := metav1.NewTime(start.Add(4 * time.Second))
+		taskRun := &pipelinev1.TaskRun{}
+		taskRun.Namespace, taskRun.Name = "ns", "tr"
+		taskRun.Status.StartTime = &start
+		taskRun.Status.Steps = []pipelinev1.StepState{
+			{ContainerState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{StartedAt: later}}},
+			{ContainerState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{StartedAt: earlier}}},
+		}
+
+		got, err := calculatePodPendingDuration(taskRun)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != 1 {
+			t.Fatalf("duration = %v, want 1s (the earliest step start)", got)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+/*
+ Copyright 2023 The Pipeline Service Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+
+	"github.com/bnallapeta/pipeline-service-exporter/collector"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/promlog"
+	"github.com/prometheus/common/promlogflag"
+	"github.com/prometheus/common/version"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// buildInfoMetric is exported on the self-metrics registry so that
+// operators can tell which build of the exporter produced a given scrape
+// without depending on the process's own Go runtime metrics.
+var buildInfoMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "pipeline_service_exporter_build_info",
+	Help: "A metric with a constant '1' value labeled by version, revision, branch, and goversion from which pipeline_service_exporter was built.",
+}, []string{"version", "revision", "branch", "goversion"})
+
+func main() {
+	var (
+		metricsPath = kingpin.Flag(
+			"web.telemetry-path",
+			"Path under which to expose exporter self-metrics.",
+		).Default("/metrics").String()
+		pipelineMetricsPath = kingpin.Flag(
+			"web.pipeline-telemetry-path",
+			"Path under which to expose pipeline-service metrics.",
+		).Default("/pipeline-metrics").String()
+		probePath = kingpin.Flag(
+			"web.probe-path",
+			"Path under which to serve ad-hoc scrapes of a single probe target.",
+		).Default("/probe").String()
+		targetsFile = kingpin.Flag(
+			"probe.targets-file",
+			"Path to a YAML file listing probe targets (name/kubeconfig/context/namespaces). Required to use the probe endpoint.",
+		).Default("").String()
+		listenAddress = kingpin.Flag(
+			"web.listen-address",
+			"Address on which to expose metrics and web interface.",
+		).Default(":9117").String()
+	)
+
+	promlogConfig := &promlog.Config{}
+	promlogflag.AddFlags(kingpin.CommandLine, promlogConfig)
+	kingpin.Version(version.Print("pipeline_service_exporter"))
+	kingpin.HelpFlag.Short('h')
+	kingpin.Parse()
+	logger := promlog.New(promlogConfig)
+
+	level.Info(logger).Log("msg", "Starting pipeline_service_exporter", "version", version.Info())
+	level.Info(logger).Log("msg", "Build context", "context", version.BuildContext())
+
+	// selfRegistry carries the exporter's own process/Go/build health so it
+	// can be scraped independently of the state of the Tekton installations
+	// it observes.
+	selfRegistry := prometheus.NewRegistry()
+	selfRegistry.MustRegister(
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewGoCollector(),
+		buildInfoMetric,
+	)
+	buildInfoMetric.WithLabelValues(version.Version, version.Revision, version.Branch, runtime.Version()).Set(1)
+
+	defaultRESTConfig, err := collector.DefaultRESTConfig()
+	if err != nil {
+		level.Error(logger).Log("msg", "Couldn't build Kubernetes REST config", "err", err)
+		os.Exit(1)
+	}
+	defaultClient, err := collector.NewClient(defaultRESTConfig)
+	if err != nil {
+		level.Error(logger).Log("msg", "Couldn't create Tekton client", "err", err)
+		os.Exit(1)
+	}
+
+	pipelineServiceCollector, err := collector.NewPipelineServiceCollector(logger, defaultClient)
+	if err != nil {
+		level.Error(logger).Log("msg", "Couldn't create collector", "err", err)
+		os.Exit(1)
+	}
+
+	pipelineServiceRegistry := prometheus.NewRegistry()
+	if err := pipelineServiceRegistry.Register(pipelineServiceCollector); err != nil {
+		level.Error(logger).Log("msg", "Couldn't register collector", "err", err)
+		os.Exit(1)
+	}
+
+	var targetProvider collector.TargetProvider
+	if *targetsFile != "" {
+		targetProvider, err = collector.NewFileTargetProvider(*targetsFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Couldn't load probe targets", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	http.Handle(*metricsPath, promhttp.HandlerFor(selfRegistry, promhttp.HandlerOpts{}))
+	http.Handle(*pipelineMetricsPath, promhttp.HandlerFor(pipelineServiceRegistry, promhttp.HandlerOpts{}))
+	http.HandleFunc(*probePath, probeHandler(logger, targetProvider))
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html>
+             <head><title>Pipeline Service Exporter</title></head>
+             <body>
+             <h1>Pipeline Service Exporter</h1>
+             <p><a href="` + *metricsPath + `">Exporter metrics</a></p>
+             <p><a href="` + *pipelineMetricsPath + `">Pipeline-service metrics</a></p>
+             <p><a href="` + *probePath + `?target=">Probe a target</a></p>
+             </body>
+             </html>`))
+	})
+
+	level.Info(logger).Log("msg", "Listening on address", "address", *listenAddress)
+	if err := http.ListenAndServe(*listenAddress, nil); err != nil {
+		level.Error(logger).Log("msg", "Error starting HTTP server", "err", err)
+		os.Exit(1)
+	}
+}
+
+// probeHandler builds an ad-hoc PipelineServiceCollector for the target
+// named by the "target" query parameter and serves its metrics on a fresh
+// registry, the same pattern blackbox_exporter and snmp_exporter use to let
+// one exporter process serve many probe targets.
+func probeHandler(logger log.Logger, targetProvider collector.TargetProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if targetProvider == nil {
+			http.Error(w, "probing is not configured: start with --probe.targets-file", http.StatusNotImplemented)
+			return
+		}
+
+		targetName := r.URL.Query().Get("target")
+		if targetName == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		target, err := targetProvider.Target(targetName)
+		if err != nil {
+			http.Error(w, "unknown target: "+targetName, http.StatusNotFound)
+			return
+		}
+
+		restConfig, err := targetProvider.RESTConfig(targetName)
+		if err != nil {
+			level.Error(logger).Log("msg", "Couldn't build REST config for target", "target", targetName, "err", err)
+			http.Error(w, "failed to build REST config for target: "+targetName, http.StatusInternalServerError)
+			return
+		}
+
+		client, err := collector.NewClient(restConfig)
+		if err != nil {
+			level.Error(logger).Log("msg", "Couldn't create Tekton client for target", "target", targetName, "err", err)
+			http.Error(w, "failed to build client for target: "+targetName, http.StatusInternalServerError)
+			return
+		}
+
+		probeCollector, err := collector.NewProbePipelineServiceCollector(log.With(logger, "target", targetName), client, target.Namespaces)
+		if err != nil {
+			level.Error(logger).Log("msg", "Couldn't create collector for target", "target", targetName, "err", err)
+			http.Error(w, "failed to build collector for target: "+targetName, http.StatusInternalServerError)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(probeCollector); err != nil {
+			level.Error(logger).Log("msg", "Couldn't register collector for target", "target", targetName, "err", err)
+			http.Error(w, "failed to register collector for target: "+targetName, http.StatusInternalServerError)
+			return
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}